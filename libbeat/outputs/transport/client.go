@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"bufio"
 	"fmt"
 	"net"
 	"sync"
@@ -14,6 +15,24 @@ type Client struct {
 
 	conn  net.Conn
 	mutex sync.Mutex
+
+	connectRetries    int
+	connectRetryDelay time.Duration
+	cancelConnect     chan struct{}
+
+	// optional write buffering
+	bufferSize    int
+	flushInterval time.Duration
+	flushTimeout  time.Duration
+	writer        *bufferedWriter
+	done          chan struct{}
+}
+
+// bufferedWriter buffers writes to a single connection. The writer is replaced
+// on reconnect, so writes in progress never block the client itself.
+type bufferedWriter struct {
+	mutex sync.Mutex
+	*bufio.Writer
 }
 
 type Config struct {
@@ -21,6 +40,17 @@ type Config struct {
 	TLS     *TLSConfig
 	Timeout time.Duration
 	Stats   *IOStats
+
+	// BufferSize enables buffered writes if > 0. Buffered data is sent when the
+	// buffer is full, on Flush, on Close and every FlushInterval (if > 0).
+	// Flushing on Close is bounded by Timeout, if set.
+	BufferSize    int
+	FlushInterval time.Duration
+
+	// ConnectRetries is the number of additional dial attempts made by Connect,
+	// waiting ConnectRetryDelay between attempts. Close aborts pending retries.
+	ConnectRetries    int
+	ConnectRetryDelay time.Duration
 }
 
 func MakeDialer(c *Config) (Dialer, error) {
@@ -59,7 +89,19 @@ func NewClient(c *Config, network, host string, defaultPort int) (*Client, error
 		return nil, err
 	}
 
-	return NewClientWithDialer(dialer, network, host, defaultPort)
+	client, err := NewClientWithDialer(dialer, network, host, defaultPort)
+	if err != nil {
+		return nil, err
+	}
+
+	client.bufferSize = c.BufferSize
+	client.flushInterval = c.FlushInterval
+	if c.Timeout > 0 {
+		client.flushTimeout = c.Timeout
+	}
+	client.connectRetries = c.ConnectRetries
+	client.connectRetryDelay = c.ConnectRetryDelay
+	return client, nil
 }
 
 func NewClientWithDialer(d Dialer, network, host string, defaultPort int) (*Client, error) {
@@ -71,30 +113,70 @@ func NewClientWithDialer(d Dialer, network, host string, defaultPort int) (*Clie
 	}
 
 	client := &Client{
-		dialer:  d,
-		network: network,
-		host:    host,
+		dialer:       d,
+		network:      network,
+		host:         host,
+		flushTimeout: defaultFlushTimeout,
 	}
 	return client, nil
 }
 
 func (c *Client) Connect() error {
+	// Dial without holding the lock, so Close and the other methods do not block
+	// on connection retries. Close or another call to Connect aborts retrying.
+	c.mutex.Lock()
+	old, writer := c.detachConn()
+	cancel := make(chan struct{})
+	c.cancelConnect = cancel
+	c.mutex.Unlock()
+
+	if old != nil {
+		_ = c.closeConn(old, writer)
+	}
+
+	conn, err := c.dial(cancel)
+
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if c.conn != nil {
-		_ = c.conn.Close()
-		c.conn = nil
+	if c.cancelConnect != cancel {
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return ErrConnectAborted
 	}
+	c.cancelConnect = nil
 
-	conn, err := c.dialer.Dial(c.network, c.host)
 	if err != nil {
 		return err
 	}
 	c.conn = conn
+
+	if c.bufferSize > 0 {
+		c.writer = &bufferedWriter{Writer: bufio.NewWriterSize(conn, c.bufferSize)}
+
+		if c.flushInterval > 0 {
+			c.done = make(chan struct{})
+			go c.flushLoop(c.done)
+		}
+	}
 	return nil
 }
 
+func (c *Client) dial(cancel <-chan struct{}) (net.Conn, error) {
+	conn, err := c.dialer.Dial(c.network, c.host)
+	for i := 0; err != nil && i < c.connectRetries; i++ {
+		debugf("connecting to %v failed (%v), retry in %v", c.host, err, c.connectRetryDelay)
+		select {
+		case <-cancel:
+			return nil, ErrConnectAborted
+		case <-time.After(c.connectRetryDelay):
+		}
+		conn, err = c.dialer.Dial(c.network, c.host)
+	}
+	return conn, err
+}
+
 func (c *Client) IsConnected() bool {
 	c.mutex.Lock()
 	b := c.conn != nil
@@ -104,15 +186,69 @@ func (c *Client) IsConnected() bool {
 
 func (c *Client) Close() error {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	conn, writer := c.detachConn()
+	c.mutex.Unlock()
 
-	if c.conn != nil {
-		debugf("closing")
-		err := c.conn.Close()
-		c.conn = nil
-		return err
+	if conn == nil {
+		return nil
+	}
+	return c.closeConn(conn, writer)
+}
+
+// detachConn aborts a pending Connect, stops the flush timer and removes the
+// active connection and write buffer from the client. Must be called with
+// c.mutex being held.
+func (c *Client) detachConn() (net.Conn, *bufferedWriter) {
+	if c.cancelConnect != nil {
+		close(c.cancelConnect)
+		c.cancelConnect = nil
+	}
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+	}
+
+	conn, writer := c.conn, c.writer
+	c.conn = nil
+	c.writer = nil
+	return conn, writer
+}
+
+// closeConn flushes the write buffer and closes a detached connection. The
+// write deadline bounds the flush and unblocks writes still in progress, in
+// case the peer stops reading.
+func (c *Client) closeConn(conn net.Conn, writer *bufferedWriter) error {
+	debugf("closing")
+
+	var flushErr error
+	if writer != nil {
+		_ = conn.SetWriteDeadline(time.Now().Add(c.flushTimeout))
+		writer.mutex.Lock()
+		flushErr = writer.Flush()
+		writer.mutex.Unlock()
+	}
+
+	err := conn.Close()
+	if err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+func (c *Client) flushLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.Flush(); err != nil {
+				debugf("flush failed: %v", err)
+			}
+		}
 	}
-	return nil
 }
 
 func (c *Client) getConn() net.Conn {
@@ -139,23 +275,89 @@ func (c *Client) Write(b []byte) (int, error) {
 		return 0, ErrNotConnected
 	}
 
-	n, err := c.conn.Write(b)
+	if c.bufferSize > 0 {
+		return c.writeBuffered(b)
+	}
+
+	n, err := conn.Write(b)
 	return n, c.handleError(err)
 }
 
+func (c *Client) getWriter() *bufferedWriter {
+	c.mutex.Lock()
+	writer := c.writer
+	c.mutex.Unlock()
+	return writer
+}
+
+func (c *Client) writeBuffered(b []byte) (int, error) {
+	writer := c.getWriter()
+	if writer == nil {
+		return 0, ErrNotConnected
+	}
+
+	writer.mutex.Lock()
+	n, err := writer.Write(b)
+	writer.mutex.Unlock()
+
+	return n, c.handleBufferedError(writer, err)
+}
+
+// Flush sends all buffered data to the connection. Flush is a no-op if write
+// buffering is disabled.
+func (c *Client) Flush() error {
+	if c.bufferSize <= 0 {
+		return nil
+	}
+
+	writer := c.getWriter()
+	if writer == nil {
+		return ErrNotConnected
+	}
+
+	writer.mutex.Lock()
+	err := writer.Flush()
+	writer.mutex.Unlock()
+
+	return c.handleBufferedError(writer, err)
+}
+
+// handleBufferedError closes the connection on any error. The buffered writer
+// keeps returning the first error it encountered, so the connection can not be
+// used anymore, even after temporary errors or timeouts. The connection is only
+// closed if writer still belongs to the active connection.
+func (c *Client) handleBufferedError(writer *bufferedWriter, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	debugf("handle buffered write error: %v", err)
+
+	c.mutex.Lock()
+	if c.writer != writer {
+		c.mutex.Unlock()
+		return err
+	}
+	conn, _ := c.detachConn()
+	c.mutex.Unlock()
+
+	// the buffer can not be flushed anymore
+	_ = conn.Close()
+	return err
+}
+
 func (c *Client) LocalAddr() net.Addr {
 	conn := c.getConn()
 	if conn != nil {
-		return c.conn.LocalAddr()
+		return conn.LocalAddr()
 	}
 	return nil
-
 }
 
 func (c *Client) RemoteAddr() net.Addr {
 	conn := c.getConn()
 	if conn != nil {
-		return c.conn.LocalAddr()
+		return conn.RemoteAddr()
 	}
 	return nil
 }
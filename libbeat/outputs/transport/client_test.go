@@ -0,0 +1,431 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestListener(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to create listener: %v", err)
+	}
+	return l
+}
+
+func newTestClient(t *testing.T, addr string) *Client {
+	client, err := NewClient(&Config{Timeout: 2 * time.Second}, "tcp", addr, 0)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestClientAddrNotConnected(t *testing.T) {
+	client := newTestClient(t, "localhost:1234")
+	assert.Nil(t, client.LocalAddr())
+	assert.Nil(t, client.RemoteAddr())
+}
+
+func TestClientRemoteAddr(t *testing.T) {
+	l := newTestListener(t)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			conn.Read(make([]byte, 1))
+		}
+	}()
+
+	client := newTestClient(t, l.Addr().String())
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	assert.Equal(t, l.Addr().String(), client.RemoteAddr().String())
+	assert.NotEqual(t, client.LocalAddr().String(), client.RemoteAddr().String())
+}
+
+type countingConn struct {
+	net.Conn
+	writes *int32
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	atomic.AddInt32(c.writes, 1)
+	return c.Conn.Write(b)
+}
+
+// newBufferedTestClient creates a client with write buffering enabled,
+// counting the number of writes to the underlying connection.
+func newBufferedTestClient(
+	t *testing.T,
+	addr string,
+	size int,
+	interval time.Duration,
+) (*Client, *int32) {
+	writes := new(int32)
+	dialer := ConnWrapper(NetDialer(2*time.Second), func(c net.Conn) net.Conn {
+		return &countingConn{c, writes}
+	})
+
+	client, err := NewClientWithDialer(dialer, "tcp", addr, 0)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.bufferSize = size
+	client.flushInterval = interval
+	return client, writes
+}
+
+// acceptAll reads all data send by the first client connecting to l.
+func acceptAll(l net.Listener) <-chan []byte {
+	ch := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			ch <- nil
+			return
+		}
+		defer conn.Close()
+
+		data, _ := ioutil.ReadAll(conn)
+		ch <- data
+	}()
+	return ch
+}
+
+func TestClientBufferedWritesCoalesce(t *testing.T) {
+	l := newTestListener(t)
+	defer l.Close()
+	received := acceptAll(l)
+
+	client, writes := newBufferedTestClient(t, l.Addr().String(), 4096, 0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	msg := []byte("0123456789")
+	for i := 0; i < 100; i++ {
+		n, err := client.Write(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, len(msg), n)
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(writes))
+
+	assert.NoError(t, client.Flush())
+	assert.Equal(t, int32(1), atomic.LoadInt32(writes))
+
+	assert.NoError(t, client.Close())
+	assert.Len(t, <-received, 100*len(msg))
+}
+
+func TestClientBufferedCloseFlushes(t *testing.T) {
+	l := newTestListener(t)
+	defer l.Close()
+	received := acceptAll(l)
+
+	client, _ := newBufferedTestClient(t, l.Addr().String(), 4096, 0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	_, err := client.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+	assert.Equal(t, "hello world", string(<-received))
+}
+
+func TestClientBufferedFlushInterval(t *testing.T) {
+	l := newTestListener(t)
+	defer l.Close()
+
+	client, writes := newBufferedTestClient(t, l.Addr().String(), 4096, 10*time.Millisecond)
+	await := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		await <- conn
+	}()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	server := <-await
+	if server == nil {
+		t.Fatal("failed to accept connection")
+	}
+	defer server.Close()
+
+	_, err := client.Write([]byte("hello"))
+	assert.NoError(t, err)
+
+	buf := make([]byte, 5)
+	server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = io.ReadFull(server, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+	assert.Equal(t, int32(1), atomic.LoadInt32(writes))
+}
+
+func TestClientConcurrentConnectWriteClose(t *testing.T) {
+	l := newTestListener(t)
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ioutil.ReadAll(conn)
+			}()
+		}
+	}()
+
+	client := newTestClient(t, l.Addr().String())
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				f()
+			}
+		}()
+	}
+
+	run(func() { client.Connect() })
+	run(func() { client.Close() })
+	for i := 0; i < 4; i++ {
+		// writes are expected to fail if the connection is closed concurrently
+		run(func() { client.Write([]byte("message")) })
+	}
+	wg.Wait()
+}
+
+// reserveAddr returns a local address no one is listening on.
+func reserveAddr(t *testing.T) string {
+	l := newTestListener(t)
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestClientConnectRetries(t *testing.T) {
+	addr := reserveAddr(t)
+
+	listener := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			listener <- nil
+			return
+		}
+		listener <- l
+		if conn, err := l.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	client, err := NewClient(&Config{
+		Timeout:           time.Second,
+		ConnectRetries:    50,
+		ConnectRetryDelay: 20 * time.Millisecond,
+	}, "tcp", addr, 0)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	err = client.Connect()
+	if l := <-listener; l != nil {
+		defer l.Close()
+	} else {
+		t.Skipf("failed to listen on %v", addr)
+	}
+	assert.NoError(t, err)
+	assert.True(t, client.IsConnected())
+	client.Close()
+}
+
+func TestClientConnectRetriesExhausted(t *testing.T) {
+	attempts := 0
+	dialer := DialerFunc(func(network, addr string) (net.Conn, error) {
+		attempts++
+		return nil, errors.New("connection refused")
+	})
+
+	client, err := NewClientWithDialer(dialer, "tcp", "localhost:1234", 0)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.connectRetries = 3
+	client.connectRetryDelay = time.Millisecond
+
+	assert.Error(t, client.Connect())
+	assert.Equal(t, 4, attempts)
+	assert.False(t, client.IsConnected())
+}
+
+func TestClientBufferedWriteTimeoutCloses(t *testing.T) {
+	l := newTestListener(t)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ioutil.ReadAll(conn)
+			}()
+		}
+	}()
+
+	client, _ := newBufferedTestClient(t, l.Addr().String(), 4096, 0)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	_, err := client.Write([]byte("hello"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.SetWriteDeadline(time.Now().Add(-time.Second)))
+	err = client.Flush()
+	if nerr, ok := err.(net.Error); assert.True(t, ok) {
+		assert.True(t, nerr.Timeout())
+	}
+	assert.False(t, client.IsConnected())
+
+	// reconnect recovers from the timeout
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to reconnect: %v", err)
+	}
+	_, err = client.Write([]byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Flush())
+}
+
+func TestClientCloseAbortsConnectRetries(t *testing.T) {
+	dialer := DialerFunc(func(network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	client, err := NewClientWithDialer(dialer, "tcp", "localhost:1234", 0)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.connectRetries = 1000
+	client.connectRetryDelay = 10 * time.Millisecond
+
+	connectErr := make(chan error, 1)
+	go func() {
+		connectErr <- client.Connect()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// methods must not block while Connect is retrying
+	start := time.Now()
+	assert.False(t, client.IsConnected())
+	assert.NoError(t, client.Close())
+	assert.True(t, time.Since(start) < time.Second)
+
+	select {
+	case err := <-connectErr:
+		assert.Equal(t, ErrConnectAborted, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not abort Connect")
+	}
+	assert.False(t, client.IsConnected())
+}
+
+// newStalledClient creates a buffered client connected via in-memory pipes
+// whose peers never read.
+func newStalledClient(t *testing.T) *Client {
+	dialer := DialerFunc(func(network, addr string) (net.Conn, error) {
+		_, conn := net.Pipe()
+		return conn, nil
+	})
+
+	client, err := NewClientWithDialer(dialer, "tcp", "localhost:1234", 0)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.bufferSize = 16
+	client.flushTimeout = 50 * time.Millisecond
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	return client
+}
+
+// blockWrite starts a write exceeding the buffer size, blocking until the
+// connection is closed.
+func blockWrite(client *Client) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		_, err := client.Write(make([]byte, 64))
+		ch <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	return ch
+}
+
+// awaitReturn fails the test if f does not return within 2 seconds.
+func awaitReturn(t *testing.T, name string, f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%v blocked", name)
+	}
+}
+
+func TestClientBufferedCloseStalledPeer(t *testing.T) {
+	client := newStalledClient(t)
+	writeErr := blockWrite(client)
+
+	awaitReturn(t, "IsConnected", func() { assert.True(t, client.IsConnected()) })
+	awaitReturn(t, "SetWriteDeadline", func() {
+		client.SetWriteDeadline(time.Now().Add(time.Minute))
+	})
+	awaitReturn(t, "Close", func() { client.Close() })
+	awaitReturn(t, "Write", func() { assert.Error(t, <-writeErr) })
+	assert.False(t, client.IsConnected())
+}
+
+func TestClientBufferedReconnectStalledPeer(t *testing.T) {
+	client := newStalledClient(t)
+	defer client.Close()
+	writeErr := blockWrite(client)
+
+	awaitReturn(t, "Connect", func() { assert.NoError(t, client.Connect()) })
+	awaitReturn(t, "Write", func() { assert.Error(t, <-writeErr) })
+
+	// failing write on the old connection must not close the new connection
+	assert.True(t, client.IsConnected())
+}
@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FramedReader reads length-prefixed messages from a Client. The length prefix
+// is an unsigned integer in network byte order with a width of 1, 2, 4 or 8
+// bytes.
+//
+// The FramedReader buffers data read from the client. Call Reset after
+// reconnecting the client, to drop data buffered from the old connection.
+//
+// If reading fails after a message has been partially read (e.g. due to the
+// read deadline), the client is closed and buffered data is dropped, as the
+// message boundaries in the remaining stream are lost.
+type FramedReader struct {
+	client  *Client
+	reader  *bufio.Reader
+	width   int
+	maxSize uint64
+	timeout time.Duration
+	header  [8]byte
+}
+
+// DefaultMaxFrameSize is the maximum message size used if no maximum size is
+// configured.
+const DefaultMaxFrameSize = 10 * 1024 * 1024
+
+const maxInt = int(^uint(0) >> 1)
+
+var ErrFrameTooLarge = errors.New("frame exceeds maximum message size")
+
+// NewFramedReader creates a FramedReader reading messages from client. Messages
+// larger than maxSize are rejected. If maxSize is 0, DefaultMaxFrameSize is
+// used. If timeout > 0, a read deadline is set on the client before reading a
+// message.
+func NewFramedReader(
+	client *Client,
+	width int,
+	maxSize uint64,
+	timeout time.Duration,
+) (*FramedReader, error) {
+	switch width {
+	case 1, 2, 4, 8:
+	default:
+		return nil, fmt.Errorf("unsupported frame length width %v", width)
+	}
+	if maxSize == 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+
+	return &FramedReader{
+		client:  client,
+		reader:  bufio.NewReader(client),
+		width:   width,
+		maxSize: maxSize,
+		timeout: timeout,
+	}, nil
+}
+
+// Reset drops all buffered data.
+func (r *FramedReader) Reset() {
+	r.reader.Reset(r.client)
+}
+
+// ReadMessage reads the next complete message. If the message exceeds the
+// maximum message size, the client is closed and ErrFrameTooLarge is returned,
+// as the remaining stream can not be parsed anymore.
+func (r *FramedReader) ReadMessage() ([]byte, error) {
+	if r.timeout > 0 {
+		if err := r.client.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+			return nil, err
+		}
+	}
+
+	hdr := r.header[:r.width]
+	if n, err := io.ReadFull(r.reader, hdr); err != nil {
+		if n > 0 {
+			r.abort(err)
+		}
+		return nil, err
+	}
+
+	var size uint64
+	switch r.width {
+	case 1:
+		size = uint64(hdr[0])
+	case 2:
+		size = uint64(binary.BigEndian.Uint16(hdr))
+	case 4:
+		size = uint64(binary.BigEndian.Uint32(hdr))
+	case 8:
+		size = binary.BigEndian.Uint64(hdr)
+	}
+
+	if size > r.maxSize || size > uint64(maxInt) {
+		r.abort(ErrFrameTooLarge)
+		return nil, ErrFrameTooLarge
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r.reader, msg); err != nil {
+		r.abort(err)
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (r *FramedReader) abort(err error) {
+	debugf("reading frame failed (%v), closing connection", err)
+	_ = r.client.Close()
+	r.Reset()
+}
@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newPipeClient creates a connected client reading from an in-memory pipe. The
+// server side of the pipe is returned.
+func newPipeClient(t *testing.T) (net.Conn, *Client) {
+	server, conn := net.Pipe()
+	dialer := DialerFunc(func(network, addr string) (net.Conn, error) {
+		return conn, nil
+	})
+
+	client, err := NewClientWithDialer(dialer, "tcp", "localhost:1234", 0)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	return server, client
+}
+
+func TestFramedReaderInvalidWidth(t *testing.T) {
+	_, err := NewFramedReader(&Client{}, 3, 0, 0)
+	assert.Error(t, err)
+}
+
+func TestFramedReaderSplitReads(t *testing.T) {
+	server, client := newPipeClient(t)
+	defer server.Close()
+	defer client.Close()
+
+	// messages 'hello', '' and 'world!' with 2 byte length prefix, split
+	// across frame boundaries
+	chunks := [][]byte{
+		{0},
+		{5, 'h', 'e'},
+		{'l', 'l', 'o', 0, 0, 0},
+		{6, 'w', 'o', 'r'},
+		{'l'},
+		{'d', '!'},
+	}
+	go func() {
+		for _, chunk := range chunks {
+			server.Write(chunk)
+		}
+	}()
+
+	reader, err := NewFramedReader(client, 2, 1024, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	for _, expected := range []string{"hello", "", "world!"} {
+		msg, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		assert.Equal(t, expected, string(msg))
+	}
+}
+
+func TestFramedReaderTooLarge(t *testing.T) {
+	servers := make(chan net.Conn, 2)
+	dialer := DialerFunc(func(network, addr string) (net.Conn, error) {
+		server, conn := net.Pipe()
+		servers <- server
+		return conn, nil
+	})
+
+	client, err := NewClientWithDialer(dialer, "tcp", "localhost:1234", 0)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// oversized frame followed by a valid frame, received in a single read
+	server := <-servers
+	defer server.Close()
+	go server.Write([]byte{0, 9, 0, 2, 'x', 'y'})
+
+	reader, err := NewFramedReader(client, 2, 4, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	_, err = reader.ReadMessage()
+	assert.Equal(t, ErrFrameTooLarge, err)
+	assert.False(t, client.IsConnected())
+
+	// data from the old connection must not be returned after reconnect
+	if err := client.Connect(); err != nil {
+		t.Fatalf("failed to reconnect: %v", err)
+	}
+	server = <-servers
+	defer server.Close()
+	go server.Write([]byte{0, 1, 'z'})
+
+	msg, err := reader.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "z", string(msg))
+}
+
+func TestFramedReaderTimeout(t *testing.T) {
+	server, client := newPipeClient(t)
+	defer server.Close()
+	defer client.Close()
+
+	reader, err := NewFramedReader(client, 1, 0, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	_, err = reader.ReadMessage()
+	if nerr, ok := err.(net.Error); assert.True(t, ok) {
+		assert.True(t, nerr.Timeout())
+	}
+
+	// no data has been read, so the connection can still be used
+	assert.True(t, client.IsConnected())
+}
+
+func TestFramedReaderTimeoutPartialFrame(t *testing.T) {
+	server, client := newPipeClient(t)
+	defer server.Close()
+	defer client.Close()
+
+	// 5 byte message 'hello', but only 'he' is send before the timeout
+	go server.Write([]byte{0, 5, 'h', 'e'})
+
+	reader, err := NewFramedReader(client, 2, 1024, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	_, err = reader.ReadMessage()
+	if nerr, ok := err.(net.Error); assert.True(t, ok) {
+		assert.True(t, nerr.Timeout())
+	}
+	assert.False(t, client.IsConnected())
+
+	// the remaining payload must not be parsed as a new frame
+	go server.Write([]byte{'l', 'l', 'o'})
+	_, err = reader.ReadMessage()
+	assert.Equal(t, ErrNotConnected, err)
+}
+
+func TestFramedReaderTooLargeDefaultMax(t *testing.T) {
+	server, client := newPipeClient(t)
+	defer server.Close()
+
+	go server.Write([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	reader, err := NewFramedReader(client, 8, 0, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	_, err = reader.ReadMessage()
+	assert.Equal(t, ErrFrameTooLarge, err)
+	assert.False(t, client.IsConnected())
+}
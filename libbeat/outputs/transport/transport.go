@@ -3,6 +3,7 @@ package transport
 import (
 	"errors"
 	"net"
+	"time"
 
 	"github.com/elastic/beats/libbeat/logp"
 )
@@ -13,8 +14,13 @@ type Dialer interface {
 
 type DialerFunc func(network, address string) (net.Conn, error)
 
+// defaultFlushTimeout bounds flushing the write buffer on Close, if no timeout
+// is configured.
+const defaultFlushTimeout = 1 * time.Second
+
 var (
-	ErrNotConnected = errors.New("client is not connected")
+	ErrNotConnected   = errors.New("client is not connected")
+	ErrConnectAborted = errors.New("connect aborted")
 
 	debugf = logp.MakeDebug("transport")
 )